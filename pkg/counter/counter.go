@@ -2,18 +2,109 @@ package counter
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
 // WordCount represents the frequency count of words
 type WordCount map[string]int
 
+// WordFreq is a single word and its frequency
+type WordFreq struct {
+	Word  string `json:"word"`
+	Count int    `json:"count"`
+}
+
+// TopN returns the n most frequent words, sorted by descending count and then ascending
+// word so the order is deterministic. If n <= 0 or n exceeds the number of words, all
+// words are returned.
+func (wc WordCount) TopN(n int) []WordFreq {
+	freqs := make([]WordFreq, 0, len(wc))
+	for w, c := range wc {
+		freqs = append(freqs, WordFreq{Word: w, Count: c})
+	}
+
+	sort.Slice(freqs, func(i, j int) bool {
+		if freqs[i].Count != freqs[j].Count {
+			return freqs[i].Count > freqs[j].Count
+		}
+		return freqs[i].Word < freqs[j].Word
+	})
+
+	if n > 0 && n < len(freqs) {
+		freqs = freqs[:n]
+	}
+	return freqs
+}
+
+// Merge returns a new WordCount holding the summed counts of wc and other. Neither input
+// is modified.
+func (wc WordCount) Merge(other WordCount) WordCount {
+	merged := make(WordCount, max(len(wc), len(other)))
+	for w, c := range wc {
+		merged[w] += c
+	}
+	for w, c := range other {
+		merged[w] += c
+	}
+	return merged
+}
+
+// Diff returns wc[word] - other[word] for every word present in either map. Words missing
+// from one side count as zero there; words whose counts are equal appear with a delta of 0.
+func (wc WordCount) Diff(other WordCount) map[string]int {
+	diff := make(map[string]int, max(len(wc), len(other)))
+	for w, c := range wc {
+		diff[w] += c
+	}
+	for w, c := range other {
+		diff[w] -= c
+	}
+	return diff
+}
+
+// WriteCSV writes wc to w as CSV with a "word,count" header, in TopN order
+func WriteCSV(w io.Writer, wc WordCount) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"word", "count"}); err != nil {
+		return fmt.Errorf("write csv header: %w", err)
+	}
+	for _, f := range wc.TopN(0) {
+		if err := cw.Write([]string{f.Word, strconv.Itoa(f.Count)}); err != nil {
+			return fmt.Errorf("write csv row: %w", err)
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("write csv: %w", err)
+	}
+	return nil
+}
+
+// WriteJSON writes wc to w as a JSON array of {"word","count"} objects, in TopN order
+func WriteJSON(w io.Writer, wc WordCount) error {
+	if err := json.NewEncoder(w).Encode(wc.TopN(0)); err != nil {
+		return fmt.Errorf("write json: %w", err)
+	}
+	return nil
+}
+
 // SegmentResult holds the result from processing a file segment
 type SegmentResult struct {
 	SegmentID int
@@ -28,29 +119,108 @@ type Counter struct {
 	mu           sync.Mutex
 	consolidated WordCount
 	wordPattern  *regexp.Regexp
+	unicode      bool // letters are any unicode.IsLetter rune, not just ASCII
+
+	minWordLength int                 // words with fewer characters are not counted
+	stopWords     map[string]struct{} // lowercased words that are never counted
+}
+
+// Option configures optional Counter behavior
+type Option func(*Counter)
+
+// WithMinLength drops words shorter than n characters (runes, not bytes)
+func WithMinLength(n int) Option {
+	return func(c *Counter) {
+		c.minWordLength = n
+	}
+}
+
+// WithStopWords drops the given words. Matching happens after lowercasing, so the list is
+// case-insensitive.
+func WithStopWords(words ...string) Option {
+	return func(c *Counter) {
+		if c.stopWords == nil {
+			c.stopWords = make(map[string]struct{}, len(words))
+		}
+		for _, w := range words {
+			c.stopWords[strings.ToLower(w)] = struct{}{}
+		}
+	}
 }
 
-// NewCounter creates a new Counter instance
-func NewCounter() *Counter {
-	return &Counter{
+// NewCounter creates a new Counter instance that treats only ASCII a-z/A-Z as letters
+func NewCounter(opts ...Option) *Counter {
+	c := &Counter{
 		consolidated: make(WordCount),
 		wordPattern:  regexp.MustCompile(`[a-zA-Z]+`),
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// NewCounterUnicode creates a new Counter instance that treats any Unicode letter
+// (accented Latin, Cyrillic, CJK, ...) as a word character
+func NewCounterUnicode(opts ...Option) *Counter {
+	c := &Counter{
+		consolidated: make(WordCount),
+		wordPattern:  regexp.MustCompile(`\p{L}+`),
+		unicode:      true,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 type FileSegment struct {
-	ID      int
-	Start   int64
-	End     int64 // exclusive end of owned range
-	ReadEnd int64 // exclusive end to read (End + overlap)
+	ID        int
+	Start     int64
+	End       int64 // exclusive end of owned range
+	ReadStart int64 // start to read (Start minus a short lookback)
+	ReadEnd   int64 // exclusive end to read (End + overlap), extended to finish an owned word
+}
+
+// owns reports whether a word starting at offset is counted by this segment
+func (s FileSegment) owns(offset int64) bool {
+	return offset >= s.Start && offset < s.End
+}
+
+// lookbackBytes is how far before its Start a segment begins reading, so it can tell
+// whether the character preceding Start is a letter (i.e. Start is mid-word). It must
+// cover the longest UTF-8 encoding of a single rune.
+const lookbackBytes = utf8.UTFMax
+
+// CountFileConcurrently partitions the file into N byte segments and counts words concurrently
+// using the default ASCII rules of NewCounter.
+func CountFileConcurrently(filePath string, numSegments int) ([]SegmentResult, WordCount, error) {
+	return NewCounter().CountFileConcurrentlyCtx(context.Background(), filePath, numSegments)
+}
+
+// CountFileConcurrentlyCtx is CountFileConcurrently with cancellation: workers stop reading
+// once ctx is done and the error wraps ctx.Err().
+func CountFileConcurrentlyCtx(ctx context.Context, filePath string, numSegments int) ([]SegmentResult, WordCount, error) {
+	return NewCounter().CountFileConcurrentlyCtx(ctx, filePath, numSegments)
 }
 
 // CountFileConcurrently partitions the file into N byte segments and counts words concurrently.
 //
 // Segments are defined by byte ranges (not line ranges). To avoid missing words that cross a
 // segment boundary, each segment (except the last) reads past its owned End by an overlap
-// window and only counts words whose start offset is within [Start, End).
-func CountFileConcurrently(filePath string, numSegments int) ([]SegmentResult, WordCount, error) {
+// window and only counts words whose start offset is within [Start, End). A word that starts
+// inside the owned range but is longer than the overlap is read to its end. Each segment also
+// reads a few bytes before Start so a word already in progress at Start is left to the
+// segment that owns its first character.
+func (c *Counter) CountFileConcurrently(filePath string, numSegments int) ([]SegmentResult, WordCount, error) {
+	return c.CountFileConcurrentlyCtx(context.Background(), filePath, numSegments)
+}
+
+// CountFileConcurrentlyCtx is CountFileConcurrently with cancellation. Each worker checks ctx
+// before every read and returns early once it is done; the first such failure is returned as
+// "segment N: " wrapping ctx.Err(). Workers that are still running finish into a buffered
+// channel, so none are leaked.
+func (c *Counter) CountFileConcurrentlyCtx(ctx context.Context, filePath string, numSegments int) ([]SegmentResult, WordCount, error) {
 	if numSegments < 1 {
 		return nil, nil, fmt.Errorf("segments must be >= 1")
 	}
@@ -75,11 +245,13 @@ func CountFileConcurrently(filePath string, numSegments int) ([]SegmentResult, W
 
 	for _, seg := range segments {
 		seg := seg
-		r := io.NewSectionReader(f, seg.Start, seg.ReadEnd-seg.Start)
+		// Let the reader run to end of file; the scan itself stops at ReadEnd unless it is
+		// finishing a word that started in the owned range.
+		r := io.NewSectionReader(f, seg.ReadStart, st.Size()-seg.ReadStart)
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			wc, err := countWordsInOwnedRange(r, seg.Start, seg.End)
+			wc, err := c.countWordsInOwnedRange(ctx, r, seg)
 			resultsCh <- SegmentResult{
 				SegmentID: seg.ID,
 				StartByte: seg.Start,
@@ -107,14 +279,166 @@ func CountFileConcurrently(filePath string, numSegments int) ([]SegmentResult, W
 		return segmentResults[i].SegmentID < segmentResults[j].SegmentID
 	})
 
-	consolidated := make(WordCount)
-	for _, res := range segmentResults {
-		for w, c := range res.WordCount {
-			consolidated[w] += c
+	return segmentResults, c.Consolidate(segmentResults), nil
+}
+
+// CountReader counts words from r in a single streaming pass using the default ASCII rules
+// of NewCounter. It works on non-seekable input such as stdin or a network stream.
+func CountReader(r io.Reader) (WordCount, error) {
+	return NewCounter().CountReader(r)
+}
+
+// CountReader counts words from r in a single streaming pass. It uses the same tokenization
+// as CountFileConcurrently, so both return identical counts for the same content.
+func (c *Counter) CountReader(r io.Reader) (WordCount, error) {
+	// A single segment owning every offset, so no word is ever excluded or cut short.
+	whole := FileSegment{ID: 1, Start: 0, End: math.MaxInt64, ReadStart: 0, ReadEnd: math.MaxInt64}
+	wc, err := c.countWordsInOwnedRange(context.Background(), r, whole)
+	if err != nil {
+		return nil, fmt.Errorf("read input: %w", err)
+	}
+	return wc, nil
+}
+
+// minSegmentBytes is the smallest segment CountFileAuto will create. Below this, goroutine
+// and overlap-read overhead outweighs the benefit of splitting the file further.
+const minSegmentBytes = 256 * 1024
+
+// CountFileAuto counts words like CountFileConcurrently using the default ASCII rules of
+// NewCounter, choosing the segment count itself.
+func CountFileAuto(filePath string) ([]SegmentResult, WordCount, error) {
+	return NewCounter().CountFileAuto(filePath)
+}
+
+// CountFileAuto counts words like CountFileConcurrently, choosing the segment count itself.
+//
+// It uses one segment per CPU (runtime.NumCPU), but never more than
+// fileSize / minSegmentBytes segments, and always at least one. Files smaller than
+// 2 * minSegmentBytes are therefore read as a single segment with no overlap.
+func (c *Counter) CountFileAuto(filePath string) ([]SegmentResult, WordCount, error) {
+	st, err := os.Stat(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("stat file: %w", err)
+	}
+	return c.CountFileConcurrently(filePath, autoSegmentCount(st.Size(), runtime.NumCPU()))
+}
+
+// autoSegmentCount implements the CountFileAuto heuristic for a file of fileSize bytes
+func autoSegmentCount(fileSize int64, numCPU int) int {
+	numSegments := max(numCPU, 1)
+	if bySize := fileSize / minSegmentBytes; bySize < int64(numSegments) {
+		numSegments = max(int(bySize), 1)
+	}
+	return numSegments
+}
+
+// gzipMagic is the two-byte header that starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// CountFileMaybeGzip counts words in filePath using the default ASCII rules of NewCounter,
+// decompressing it first if it is gzip.
+func CountFileMaybeGzip(filePath string) (WordCount, error) {
+	return NewCounter().CountFileMaybeGzip(filePath)
+}
+
+// CountFileMaybeGzip counts words in filePath, decompressing it first if it has a .gz
+// extension or starts with the gzip magic bytes. Gzip streams cannot be split into byte
+// ranges, so they are counted in a single streaming pass with CountReader; plain files go
+// through the concurrent CountFileAuto path.
+func (c *Counter) CountFileMaybeGzip(filePath string) (WordCount, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("open file: %w", err)
+	}
+	defer f.Close()
+
+	header := make([]byte, len(gzipMagic))
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+	isGzip := bytes.Equal(header[:n], gzipMagic) || strings.EqualFold(filepath.Ext(filePath), ".gz")
+
+	if !isGzip {
+		_, consolidated, err := c.CountFileAuto(filePath)
+		return consolidated, err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seek file: %w", err)
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("open gzip: %w", err)
+	}
+	defer zr.Close()
+
+	return c.CountReader(zr)
+}
+
+// CountNGrams counts n-word phrases in r using the default ASCII rules of NewCounter.
+func CountNGrams(r io.Reader, n int) (map[string]int, error) {
+	return NewCounter().CountNGrams(r, n)
+}
+
+// CountNGrams counts every run of n consecutive words in r, lowercased and joined by a single
+// space, sliding one word at a time. Words are tokenized and filtered exactly as in
+// CountReader, so n == 1 gives the same counts.
+//
+// Phrases never cross a line break: each new line starts an empty window, so a line with no
+// words (e.g. all punctuation) cannot join the words around it. A word dropped by
+// WithMinLength or WithStopWords also resets the window rather than being skipped over.
+func (c *Counter) CountNGrams(r io.Reader, n int) (map[string]int, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("n-gram size must be >= 1")
+	}
+
+	br := bufio.NewReader(r)
+	counts := make(map[string]int)
+	window := make([]string, 0, n)
+	var wordBuffer []byte
+
+	flush := func() {
+		if len(wordBuffer) == 0 {
+			return
+		}
+		word := string(wordBuffer)
+		wordBuffer = wordBuffer[:0]
+		if !c.keepWord(word) {
+			window = window[:0]
+			return
+		}
+		if len(window) == n {
+			copy(window, window[1:])
+			window = window[:n-1]
+		}
+		window = append(window, word)
+		if len(window) == n {
+			counts[strings.Join(window, " ")]++
 		}
 	}
 
-	return segmentResults, consolidated, nil
+	for {
+		// ReadRune yields non-letters for non-ASCII input in ASCII mode and for invalid
+		// UTF-8, matching the byte-segment scanner.
+		ch, _, err := br.ReadRune()
+		if err != nil {
+			if err == io.EOF {
+				flush()
+				return counts, nil
+			}
+			return nil, fmt.Errorf("read input: %w", err)
+		}
+
+		if c.isLetter(ch) {
+			wordBuffer = utf8.AppendRune(wordBuffer, c.toLower(ch))
+			continue
+		}
+		flush()
+		if ch == '\n' {
+			window = window[:0]
+		}
+	}
 }
 
 func partitionFileByBytes(fileSize int64, numSegments int, overlapBytes int64) []FileSegment {
@@ -140,26 +464,35 @@ func partitionFileByBytes(fileSize int64, numSegments int, overlapBytes int64) [
 		}
 
 		segments = append(segments, FileSegment{
-			ID:      i + 1,
-			Start:   start,
-			End:     end,
-			ReadEnd: readEnd,
+			ID:        i + 1,
+			Start:     start,
+			End:       end,
+			ReadStart: max(start-lookbackBytes, 0),
+			ReadEnd:   readEnd,
 		})
 	}
 
 	return segments
 }
 
-func countWordsInOwnedRange(r io.Reader, absoluteStart int64, ownedEnd int64) (WordCount, error) {
+// countWordsInOwnedRange counts the words read from r, which begins at absolute offset
+// seg.ReadStart, keeping only words whose first byte lies in [seg.Start, seg.End).
+//
+// Scanning normally stops at seg.ReadEnd, but a word owned by the segment that is still in
+// progress there is read to its end. r must therefore be able to supply bytes past ReadEnd
+// (up to the end of the input) for words longer than the overlap window. ctx is checked
+// before each read.
+func (c *Counter) countWordsInOwnedRange(ctx context.Context, r io.Reader, seg FileSegment) (WordCount, error) {
 	const bufSize = 32 * 1024
 	buf := make([]byte, bufSize)
 
 	wc := make(WordCount)
 	var (
-		absOffset  = absoluteStart
+		absOffset  = seg.ReadStart
 		inWord     bool
 		wordStart  int64
 		wordBuffer []byte
+		carry      int // bytes of an incomplete rune kept at the front of buf
 	)
 
 	flush := func() {
@@ -167,38 +500,47 @@ func countWordsInOwnedRange(r io.Reader, absoluteStart int64, ownedEnd int64) (W
 			return
 		}
 		// Only count the word if it started inside this segment's owned range.
-		if wordStart < ownedEnd {
-			for i := range wordBuffer {
-				b := wordBuffer[i]
-				if b >= 'A' && b <= 'Z' {
-					wordBuffer[i] = b + ('a' - 'A')
-				}
+		if seg.owns(wordStart) {
+			if word := string(wordBuffer); c.keepWord(word) {
+				wc[word]++
 			}
-			wc[string(wordBuffer)]++
 		}
 		inWord = false
 		wordBuffer = wordBuffer[:0]
 	}
 
 	for {
-		n, err := r.Read(buf)
-		if n > 0 {
-			for i := 0; i < n; i++ {
-				b := buf[i]
-				isLetter := (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
-				if isLetter {
-					if !inWord {
-						inWord = true
-						wordStart = absOffset
-						wordBuffer = wordBuffer[:0]
-					}
-					wordBuffer = append(wordBuffer, b)
-				} else {
-					flush()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		n, err := r.Read(buf[carry:])
+		data := buf[:carry+n]
+		i := 0
+		for i < len(data) {
+			if absOffset >= seg.ReadEnd && !(inWord && seg.owns(wordStart)) {
+				flush()
+				return wc, nil
+			}
+			// A multibyte rune may straddle the read buffer; wait for the rest of it.
+			if c.unicode && err == nil && !utf8.FullRune(data[i:]) {
+				break
+			}
+			ch, size := c.decodeRune(data[i:])
+			if c.isLetter(ch) {
+				if !inWord {
+					inWord = true
+					wordStart = absOffset
+					wordBuffer = wordBuffer[:0]
 				}
-				absOffset++
+				wordBuffer = utf8.AppendRune(wordBuffer, c.toLower(ch))
+			} else {
+				flush()
 			}
+			absOffset += int64(size)
+			i += size
 		}
+		carry = copy(buf, data[i:])
 
 		if err != nil {
 			if err == io.EOF {
@@ -210,6 +552,43 @@ func countWordsInOwnedRange(r io.Reader, absoluteStart int64, ownedEnd int64) (W
 	}
 }
 
+// decodeRune returns the first character of p and its width in bytes. In ASCII mode every
+// byte is its own character.
+func (c *Counter) decodeRune(p []byte) (rune, int) {
+	if !c.unicode {
+		return rune(p[0]), 1
+	}
+	return utf8.DecodeRune(p)
+}
+
+// isLetter reports whether r is a word character under the counter's rules
+func (c *Counter) isLetter(r rune) bool {
+	if c.unicode {
+		return unicode.IsLetter(r)
+	}
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// toLower lowercases a word character under the counter's rules
+func (c *Counter) toLower(r rune) rune {
+	if c.unicode {
+		return unicode.ToLower(r)
+	}
+	if r >= 'A' && r <= 'Z' {
+		return r + ('a' - 'A')
+	}
+	return r
+}
+
+// keepWord reports whether a lowercased word passes the length and stop-word filters
+func (c *Counter) keepWord(word string) bool {
+	if c.minWordLength > 0 && utf8.RuneCountInString(word) < c.minWordLength {
+		return false
+	}
+	_, stop := c.stopWords[word]
+	return !stop
+}
+
 // ProcessSegment processes a segment of text and returns word frequencies
 func (c *Counter) ProcessSegment(segmentID int, lines []string, results chan<- SegmentResult, wg *sync.WaitGroup) {
 	defer wg.Done()
@@ -221,7 +600,9 @@ func (c *Counter) ProcessSegment(segmentID int, lines []string, results chan<- S
 		for _, word := range words {
 			// Normalize to lowercase for case-insensitive counting
 			word = strings.ToLower(word)
-			localCount[word]++
+			if c.keepWord(word) {
+				localCount[word]++
+			}
 		}
 	}
 
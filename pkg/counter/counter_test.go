@@ -1,10 +1,45 @@
 package counter
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"sync"
 	"testing"
+	"testing/iotest"
 )
 
+// writeTempFile writes content to a file in a per-test temp dir and returns its path
+func writeTempFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write temp file: %v", err)
+	}
+	return path
+}
+
+// assertWordCount fails the test unless got and want hold exactly the same entries
+func assertWordCount(t *testing.T, got, want WordCount) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("got %d unique words %v, want %d %v", len(got), got, len(want), want)
+	}
+	for word, expectedCount := range want {
+		if count := got[word]; count != expectedCount {
+			t.Errorf("Word '%s' count = %d, want %d", word, count, expectedCount)
+		}
+	}
+}
+
 func TestPartitionLines(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -177,6 +212,636 @@ func TestConcurrentProcessing(t *testing.T) {
 	}
 }
 
+func TestCountFileConcurrentlySegmentBoundaries(t *testing.T) {
+	path := writeTempFile(t, "hello world, Hello again")
+	want := WordCount{"hello": 2, "world": 1, "again": 1}
+
+	// More segments than bytes puts a boundary inside every word.
+	for _, numSegments := range []int{1, 2, 3, 7, 100} {
+		_, consolidated, err := CountFileConcurrently(path, numSegments)
+		if err != nil {
+			t.Fatalf("CountFileConcurrently(%d) error = %v", numSegments, err)
+		}
+		assertWordCount(t, consolidated, want)
+	}
+}
+
+func TestCountFileConcurrentlyASCIIDropsNonASCII(t *testing.T) {
+	path := writeTempFile(t, "café naïve")
+
+	_, consolidated, err := CountFileConcurrently(path, 1)
+	if err != nil {
+		t.Fatalf("CountFileConcurrently() error = %v", err)
+	}
+	assertWordCount(t, consolidated, WordCount{"caf": 1, "na": 1, "ve": 1})
+}
+
+func TestCountFileConcurrentlyUnicode(t *testing.T) {
+	path := writeTempFile(t, "Café naïve ÜBER café. 日本語 テキスト, Привет мир! über")
+	want := WordCount{
+		"café":   2,
+		"naïve":  1,
+		"über":   2,
+		"日本語":    1,
+		"テキスト":   1,
+		"привет": 1,
+		"мир":    1,
+	}
+
+	// More segments than bytes forces boundaries inside multibyte runes.
+	for _, numSegments := range []int{1, 2, 5, 16, 200} {
+		_, consolidated, err := NewCounterUnicode().CountFileConcurrently(path, numSegments)
+		if err != nil {
+			t.Fatalf("CountFileConcurrently(%d) error = %v", numSegments, err)
+		}
+		assertWordCount(t, consolidated, want)
+	}
+}
+
+func TestCountFileConcurrentlyWordLongerThanOverlap(t *testing.T) {
+	// A single word far longer than the 64KB overlap, placed so every segment
+	// boundary falls inside it.
+	longWord := strings.Repeat("abcdefghij", 30*1024)
+	path := writeTempFile(t, "alpha "+longWord+" omega")
+	want := WordCount{"alpha": 1, longWord: 1, "omega": 1}
+
+	for _, numSegments := range []int{1, 2, 4, 8} {
+		_, consolidated, err := CountFileConcurrently(path, numSegments)
+		if err != nil {
+			t.Fatalf("CountFileConcurrently(%d) error = %v", numSegments, err)
+		}
+		if len(consolidated) != len(want) {
+			t.Errorf("segments=%d: got %d unique words, want %d", numSegments, len(consolidated), len(want))
+		}
+		for word, expectedCount := range want {
+			if count := consolidated[word]; count != expectedCount {
+				t.Errorf("segments=%d: word of length %d count = %d, want %d", numSegments, len(word), count, expectedCount)
+			}
+		}
+	}
+}
+
+func TestCountFileConcurrentlyCtxCancelled(t *testing.T) {
+	path := writeTempFile(t, strings.Repeat("the quick brown fox jumps over the lazy dog\n", 100*1024))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, consolidated, err := CountFileConcurrentlyCtx(ctx, path, 4)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("CountFileConcurrentlyCtx() error = %v, want context.Canceled", err)
+	}
+	if results != nil || consolidated != nil {
+		t.Errorf("CountFileConcurrentlyCtx() returned results on cancellation")
+	}
+}
+
+// countingReader records how many bytes have been read through it
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestCountWordsInOwnedRangeStopsOnCancel(t *testing.T) {
+	text := strings.Repeat("word ", 100*1024)
+	r := &countingReader{r: strings.NewReader(text)}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	n := int64(len(text))
+	seg := FileSegment{ID: 1, Start: 0, End: n, ReadStart: 0, ReadEnd: n}
+	_, err := NewCounter().countWordsInOwnedRange(ctx, r, seg)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("countWordsInOwnedRange() error = %v, want context.Canceled", err)
+	}
+	if r.n != 0 {
+		t.Errorf("countWordsInOwnedRange() read %d bytes after cancellation, want 0", r.n)
+	}
+}
+
+func TestCountWordsInOwnedRangeRuneAcrossReads(t *testing.T) {
+	text := "naïve 日本語"
+	// One byte per Read splits every multibyte rune across reads.
+	r := iotest.OneByteReader(strings.NewReader(text))
+
+	n := int64(len(text))
+	seg := FileSegment{ID: 1, Start: 0, End: n, ReadStart: 0, ReadEnd: n}
+	wc, err := NewCounterUnicode().countWordsInOwnedRange(context.Background(), r, seg)
+	if err != nil {
+		t.Fatalf("countWordsInOwnedRange() error = %v", err)
+	}
+	assertWordCount(t, wc, WordCount{"naïve": 1, "日本語": 1})
+}
+
+func TestProcessSegmentUnicode(t *testing.T) {
+	counter := NewCounterUnicode()
+	results := make(chan SegmentResult, 1)
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go counter.ProcessSegment(1, []string{"Café CAFÉ", "日本語 café"}, results, &wg)
+	wg.Wait()
+	close(results)
+
+	result := <-results
+	assertWordCount(t, result.WordCount, WordCount{"café": 3, "日本語": 1})
+}
+
+func TestCountReaderMatchesCountFileConcurrently(t *testing.T) {
+	longWord := strings.Repeat("xyz", 40*1024)
+	inputs := map[string]string{
+		"Sample":    "The quick brown fox jumps over the lazy dog. The dog was lazy.",
+		"Empty":     "",
+		"Long word": "before " + longWord + " after " + longWord,
+	}
+	sample, err := os.ReadFile(filepath.Join("..", "..", "testdata", "sample.txt"))
+	if err != nil {
+		t.Fatalf("read sample: %v", err)
+	}
+	inputs["testdata"] = string(sample)
+
+	for name, content := range inputs {
+		t.Run(name, func(t *testing.T) {
+			path := writeTempFile(t, content)
+			_, want, err := CountFileConcurrently(path, 4)
+			if err != nil {
+				t.Fatalf("CountFileConcurrently() error = %v", err)
+			}
+
+			got, err := CountReader(strings.NewReader(content))
+			if err != nil {
+				t.Fatalf("CountReader() error = %v", err)
+			}
+			assertWordCount(t, got, want)
+
+			// Tiny reads force words across read-buffer boundaries.
+			got, err = CountReader(iotest.HalfReader(strings.NewReader(content)))
+			if err != nil {
+				t.Fatalf("CountReader() error = %v", err)
+			}
+			assertWordCount(t, got, want)
+		})
+	}
+}
+
+func TestCountReaderUnicode(t *testing.T) {
+	text := "Ünïcödé  日本語 ÜNÏCÖDÉ"
+	got, err := NewCounterUnicode().CountReader(iotest.OneByteReader(strings.NewReader(text)))
+	if err != nil {
+		t.Fatalf("CountReader() error = %v", err)
+	}
+	assertWordCount(t, got, WordCount{"ünïcödé": 2, "日本語": 1})
+}
+
+func TestCountReaderError(t *testing.T) {
+	_, err := CountReader(iotest.ErrReader(os.ErrClosed))
+	if err == nil {
+		t.Fatal("CountReader() error = nil, want error")
+	}
+}
+
+func TestMinWordLength(t *testing.T) {
+	text := "I want to go to a big city"
+	want := WordCount{"want": 1, "big": 1, "city": 1}
+
+	counter := NewCounter(WithMinLength(3))
+	results := make(chan SegmentResult, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go counter.ProcessSegment(1, []string{text}, results, &wg)
+	wg.Wait()
+	close(results)
+	assertWordCount(t, (<-results).WordCount, want)
+
+	path := writeTempFile(t, text)
+	_, consolidated, err := NewCounter(WithMinLength(3)).CountFileConcurrently(path, 3)
+	if err != nil {
+		t.Fatalf("CountFileConcurrently() error = %v", err)
+	}
+	assertWordCount(t, consolidated, want)
+}
+
+func TestMinWordLengthCountsRunes(t *testing.T) {
+	got, err := NewCounterUnicode(WithMinLength(3)).CountReader(strings.NewReader("日本 日本語 éé"))
+	if err != nil {
+		t.Fatalf("CountReader() error = %v", err)
+	}
+	assertWordCount(t, got, WordCount{"日本語": 1})
+}
+
+func TestStopWords(t *testing.T) {
+	lines := []string{"The cat and THE dog", "Of mice and men"}
+	want := WordCount{"cat": 1, "dog": 1, "mice": 1, "men": 1}
+	stop := WithStopWords("the", "AND", "of")
+
+	counter := NewCounter(stop)
+	segments := PartitionLines(lines, 2)
+	results := make(chan SegmentResult, len(segments))
+	var wg sync.WaitGroup
+	for i, segment := range segments {
+		wg.Add(1)
+		go counter.ProcessSegment(i+1, segment, results, &wg)
+	}
+	wg.Wait()
+	close(results)
+
+	var segmentResults []SegmentResult
+	for result := range results {
+		segmentResults = append(segmentResults, result)
+	}
+	assertWordCount(t, counter.Consolidate(segmentResults), want)
+
+	path := writeTempFile(t, strings.Join(lines, "\n"))
+	_, consolidated, err := NewCounter(stop).CountFileConcurrently(path, 4)
+	if err != nil {
+		t.Fatalf("CountFileConcurrently() error = %v", err)
+	}
+	assertWordCount(t, consolidated, want)
+}
+
+func TestAutoSegmentCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileSize int64
+		numCPU   int
+		want     int
+	}{
+		{"Empty file", 0, 8, 1},
+		{"Tiny file", 10, 8, 1},
+		{"Just under two segments", 2*minSegmentBytes - 1, 8, 1},
+		{"Limited by size", 3 * minSegmentBytes, 8, 3},
+		{"Limited by CPUs", 100 * minSegmentBytes, 8, 8},
+		{"Single CPU", 100 * minSegmentBytes, 1, 1},
+		{"Zero CPUs", 100 * minSegmentBytes, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := autoSegmentCount(tt.fileSize, tt.numCPU); got != tt.want {
+				t.Errorf("autoSegmentCount(%d, %d) = %d, want %d", tt.fileSize, tt.numCPU, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountFileAuto(t *testing.T) {
+	t.Run("Tiny file", func(t *testing.T) {
+		path := writeTempFile(t, "tiny file")
+		results, consolidated, err := CountFileAuto(path)
+		if err != nil {
+			t.Fatalf("CountFileAuto() error = %v", err)
+		}
+		if len(results) != 1 {
+			t.Errorf("CountFileAuto() used %d segments, want 1", len(results))
+		}
+		assertWordCount(t, consolidated, WordCount{"tiny": 1, "file": 1})
+	})
+
+	t.Run("Empty file", func(t *testing.T) {
+		path := writeTempFile(t, "")
+		results, consolidated, err := CountFileAuto(path)
+		if err != nil {
+			t.Fatalf("CountFileAuto() error = %v", err)
+		}
+		if len(results) != 0 || len(consolidated) != 0 {
+			t.Errorf("CountFileAuto() = %d segments, %d words, want none", len(results), len(consolidated))
+		}
+	})
+
+	t.Run("Large file", func(t *testing.T) {
+		line := "large synthetic file\n"
+		numCPU := runtime.NumCPU()
+		repeat := numCPU*minSegmentBytes/len(line) + 1
+		path := writeTempFile(t, strings.Repeat(line, repeat))
+
+		results, consolidated, err := CountFileAuto(path)
+		if err != nil {
+			t.Fatalf("CountFileAuto() error = %v", err)
+		}
+		if len(results) != numCPU {
+			t.Errorf("CountFileAuto() used %d segments, want %d", len(results), numCPU)
+		}
+		assertWordCount(t, consolidated, WordCount{"large": repeat, "synthetic": repeat, "file": repeat})
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		if _, _, err := CountFileAuto(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+			t.Error("CountFileAuto() error = nil, want error")
+		}
+	})
+}
+
+func TestCountFileMaybeGzip(t *testing.T) {
+	sample, err := os.ReadFile(filepath.Join("..", "..", "testdata", "sample.txt"))
+	if err != nil {
+		t.Fatalf("read sample: %v", err)
+	}
+	dir := t.TempDir()
+
+	plainPath := filepath.Join(dir, "sample.txt")
+	if err := os.WriteFile(plainPath, sample, 0o644); err != nil {
+		t.Fatalf("write plain file: %v", err)
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	if _, err := zw.Write(sample); err != nil {
+		t.Fatalf("gzip sample: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("gzip sample: %v", err)
+	}
+	gzPath := filepath.Join(dir, "sample.txt.gz")
+	// Same bytes without the extension, so detection must rely on the magic bytes.
+	sniffPath := filepath.Join(dir, "sample.bin")
+	for _, p := range []string{gzPath, sniffPath} {
+		if err := os.WriteFile(p, gz.Bytes(), 0o644); err != nil {
+			t.Fatalf("write gzip file: %v", err)
+		}
+	}
+
+	want, err := CountFileMaybeGzip(plainPath)
+	if err != nil {
+		t.Fatalf("CountFileMaybeGzip(plain) error = %v", err)
+	}
+	if len(want) == 0 {
+		t.Fatal("CountFileMaybeGzip(plain) returned no words")
+	}
+	_, direct, err := CountFileConcurrently(plainPath, 4)
+	if err != nil {
+		t.Fatalf("CountFileConcurrently() error = %v", err)
+	}
+	assertWordCount(t, want, direct)
+
+	for _, p := range []string{gzPath, sniffPath} {
+		got, err := CountFileMaybeGzip(p)
+		if err != nil {
+			t.Fatalf("CountFileMaybeGzip(%s) error = %v", filepath.Base(p), err)
+		}
+		assertWordCount(t, got, want)
+	}
+}
+
+func TestCountFileMaybeGzipInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.gz")
+	if err := os.WriteFile(path, []byte("not gzip"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := CountFileMaybeGzip(path); err == nil {
+		t.Error("CountFileMaybeGzip() error = nil, want error")
+	}
+
+	got, err := CountFileMaybeGzip(writeTempFile(t, "x"))
+	if err != nil {
+		t.Fatalf("CountFileMaybeGzip(one byte) error = %v", err)
+	}
+	assertWordCount(t, got, WordCount{"x": 1})
+}
+
+func TestCountNGrams(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		n    int
+		want map[string]int
+	}{
+		{
+			name: "Bigrams",
+			text: "the quick brown fox",
+			n:    2,
+			want: map[string]int{"the quick": 1, "quick brown": 1, "brown fox": 1},
+		},
+		{
+			name: "Trigrams with case and punctuation",
+			text: "New York, new YORK city; New York city",
+			n:    3,
+			want: map[string]int{"new york new": 1, "york new york": 1, "new york city": 2, "york city new": 1, "city new york": 1},
+		},
+		{
+			name: "Line break resets window",
+			text: "machine learning\nlearning rate",
+			n:    2,
+			want: map[string]int{"machine learning": 1, "learning rate": 1},
+		},
+		{
+			name: "Punctuation-only line does not stitch",
+			text: "alpha beta\n--- !!! ---\ngamma delta",
+			n:    2,
+			want: map[string]int{"alpha beta": 1, "gamma delta": 1},
+		},
+		{
+			name: "Fewer words than n",
+			text: "only two",
+			n:    3,
+			want: map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CountNGrams(strings.NewReader(tt.text), tt.n)
+			if err != nil {
+				t.Fatalf("CountNGrams() error = %v", err)
+			}
+			assertWordCount(t, got, tt.want)
+		})
+	}
+}
+
+func TestCountNGramsUnigramsMatchCountReader(t *testing.T) {
+	sample, err := os.ReadFile(filepath.Join("..", "..", "testdata", "sample.txt"))
+	if err != nil {
+		t.Fatalf("read sample: %v", err)
+	}
+
+	got, err := CountNGrams(bytes.NewReader(sample), 1)
+	if err != nil {
+		t.Fatalf("CountNGrams() error = %v", err)
+	}
+	want, err := CountReader(bytes.NewReader(sample))
+	if err != nil {
+		t.Fatalf("CountReader() error = %v", err)
+	}
+	assertWordCount(t, got, want)
+}
+
+func TestCountNGramsFilteredWordResetsWindow(t *testing.T) {
+	counter := NewCounterUnicode(WithStopWords("the"))
+	got, err := counter.CountNGrams(strings.NewReader("over the café roof"), 2)
+	if err != nil {
+		t.Fatalf("CountNGrams() error = %v", err)
+	}
+	assertWordCount(t, got, map[string]int{"café roof": 1})
+}
+
+func TestCountNGramsInvalidSize(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		if _, err := CountNGrams(strings.NewReader("a b"), n); err == nil {
+			t.Errorf("CountNGrams(n=%d) error = nil, want error", n)
+		}
+	}
+}
+
+func TestTopN(t *testing.T) {
+	wc := WordCount{"pear": 2, "apple": 5, "fig": 2, "kiwi": 1, "banana": 2}
+
+	tests := []struct {
+		name string
+		n    int
+		want []WordFreq
+	}{
+		{
+			name: "Ties sorted by word",
+			n:    3,
+			want: []WordFreq{{"apple", 5}, {"banana", 2}, {"fig", 2}},
+		},
+		{
+			name: "Zero returns all",
+			n:    0,
+			want: []WordFreq{{"apple", 5}, {"banana", 2}, {"fig", 2}, {"pear", 2}, {"kiwi", 1}},
+		},
+		{
+			name: "Negative returns all",
+			n:    -1,
+			want: []WordFreq{{"apple", 5}, {"banana", 2}, {"fig", 2}, {"pear", 2}, {"kiwi", 1}},
+		},
+		{
+			name: "More than available",
+			n:    100,
+			want: []WordFreq{{"apple", 5}, {"banana", 2}, {"fig", 2}, {"pear", 2}, {"kiwi", 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wc.TopN(tt.n)
+			if len(got) != len(tt.want) {
+				t.Fatalf("TopN(%d) got %d entries, want %d", tt.n, len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("TopN(%d)[%d] = %v, want %v", tt.n, i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTopNEmpty(t *testing.T) {
+	if got := (WordCount{}).TopN(5); len(got) != 0 {
+		t.Errorf("TopN() on empty count = %v, want empty", got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := WordCount{"the": 2, "cat": 1}
+	b := WordCount{"the": 3, "dog": 4}
+
+	merged := a.Merge(b)
+	assertWordCount(t, merged, WordCount{"the": 5, "cat": 1, "dog": 4})
+
+	// Inputs must be untouched, and the result must not alias them.
+	assertWordCount(t, a, WordCount{"the": 2, "cat": 1})
+	assertWordCount(t, b, WordCount{"the": 3, "dog": 4})
+	merged["cat"] = 100
+	if a["cat"] != 1 {
+		t.Errorf("Merge() result aliases receiver")
+	}
+
+	assertWordCount(t, WordCount(nil).Merge(b), b)
+}
+
+func TestDiff(t *testing.T) {
+	a := WordCount{"the": 5, "cat": 1, "same": 2}
+	b := WordCount{"the": 3, "dog": 4, "same": 2}
+
+	got := a.Diff(b)
+	want := map[string]int{"the": 2, "cat": 1, "dog": -4, "same": 0}
+	if len(got) != len(want) {
+		t.Errorf("Diff() got %d entries %v, want %d", len(got), got, len(want))
+	}
+	for word, delta := range want {
+		if d, ok := got[word]; !ok || d != delta {
+			t.Errorf("Diff()[%q] = %d (present %v), want %d", word, d, ok, delta)
+		}
+	}
+
+	assertWordCount(t, a, WordCount{"the": 5, "cat": 1, "same": 2})
+	assertWordCount(t, b, WordCount{"the": 3, "dog": 4, "same": 2})
+}
+
+func TestWriteCSV(t *testing.T) {
+	wc := WordCount{"the": 3, "say \"hi\"": 1, "a,b": 2, "dog": 2}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, wc); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("read back csv: %v", err)
+	}
+
+	want := [][]string{
+		{"word", "count"},
+		{"the", "3"},
+		{"a,b", "2"},
+		{"dog", "2"},
+		{"say \"hi\"", "1"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("WriteCSV() wrote %d records, want %d", len(records), len(want))
+	}
+	for i := range want {
+		if records[i][0] != want[i][0] || records[i][1] != want[i][1] {
+			t.Errorf("record %d = %v, want %v", i, records[i], want[i])
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	wc := WordCount{"the": 3, "fox": 1, "dog": 1}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, wc); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+
+	want := `[{"word":"the","count":3},{"word":"dog","count":1},{"word":"fox","count":1}]` + "\n"
+	if buf.String() != want {
+		t.Errorf("WriteJSON() = %s, want %s", buf.String(), want)
+	}
+
+	var got []WordFreq
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("decode json: %v", err)
+	}
+	if len(got) != 3 || got[0] != (WordFreq{"the", 3}) {
+		t.Errorf("decoded %v, want %v first", got, WordFreq{"the", 3})
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	wc := WordCount{"word": 1}
+	if err := WriteCSV(errWriter{}, wc); err == nil {
+		t.Error("WriteCSV() error = nil, want error")
+	}
+	if err := WriteJSON(errWriter{}, wc); err == nil {
+		t.Error("WriteJSON() error = nil, want error")
+	}
+}
+
+// errWriter fails every write
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, os.ErrClosed }
+
 func BenchmarkProcessSegment(b *testing.B) {
 	counter := NewCounter()
 	lines := make([]string, 1000)